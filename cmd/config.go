@@ -0,0 +1,13 @@
+package cmd
+
+import "github.com/spf13/cobra"
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Commands for working with component config/*.yaml files directly.",
+	Long:  "Commands for working with component config/*.yaml files directly.",
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// flagOverrides parses the repeatable `--flag name=value` values together
+// with any `FAB_FLAG_NAME=value` environment variables into the
+// map[string]bool that core.ComponentConfig.FlagOverrides expects. CLI
+// values take precedence over the environment fallback.
+func flagOverrides(cliFlags []string) (map[string]bool, error) {
+	overrides := map[string]bool{}
+
+	const envPrefix = "FAB_FLAG_"
+	for _, env := range os.Environ() {
+		name, value, found := strings.Cut(env, "=")
+		if !found || !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+
+		flagName := strings.ToLower(strings.TrimPrefix(name, envPrefix))
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid boolean value for flag '%s'", value, flagName)
+		}
+		overrides[flagName] = parsed
+	}
+
+	for _, cliFlag := range cliFlags {
+		name, value, found := strings.Cut(cliFlag, "=")
+		if !found {
+			return nil, fmt.Errorf("'--flag' values must be 'name=value', got '%s'", cliFlag)
+		}
+
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid boolean value for flag '%s'", value, name)
+		}
+		overrides[name] = parsed
+	}
+
+	return overrides, nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringArray("flag", []string{}, "Override a component feature flag, e.g. --flag ha=true (repeatable)")
+}
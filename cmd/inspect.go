@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/microsoft/fabrikate/core"
+	"github.com/spf13/cobra"
+)
+
+func inspect(environment string, sets []string, flagOverrides map[string]bool) (err error) {
+	componentConfig := core.NewComponentConfig(".")
+	componentConfig.CLISets = sets
+	componentConfig.FlagOverrides = flagOverrides
+
+	if err := componentConfig.LoadLayers(environment); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(componentConfig.Provenance))
+	for path := range componentConfig.Provenance {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fmt.Printf("%s\t(%s)\n", path, componentConfig.Provenance[path])
+	}
+
+	return nil
+}
+
+func newInspectCmd() *cobra.Command {
+	var sets []string
+
+	cmd := &cobra.Command{
+		Use:   "inspect <environment>",
+		Short: "Shows the effective config for an environment across all layers, and which layer each value came from.",
+		Long: `Shows the effective config for an environment after merging the system, home, project, env, and CLI config layers (see LoadLayers), and which layer supplied each value.
+eg.
+$ fab inspect prod --set data.replicas=5
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("'inspect' takes the target environment as its only argument")
+			}
+
+			cliFlags, err := cmd.Flags().GetStringArray("flag")
+			if err != nil {
+				return err
+			}
+			overrides, err := flagOverrides(cliFlags)
+			if err != nil {
+				return err
+			}
+
+			return inspect(args[0], sets, overrides)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sets, "set", []string{}, "Override a config value for this inspection only, e.g. --set data.replicas=5")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newInspectCmd())
+}
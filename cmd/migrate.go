@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/fabrikate/core/migrations"
+	"github.com/spf13/cobra"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+type migrateCmdOpts struct {
+	from   string
+	to     string
+	dryRun bool
+	backup bool
+}
+
+func migrate(componentPath string, opts migrateCmdOpts) (err error) {
+	to := opts.to
+	if to == "" {
+		to = migrations.CurrentVersion
+	}
+
+	configDirs, err := findConfigDirs(componentPath)
+	if err != nil {
+		return fmt.Errorf("walking '%s': %w", componentPath, err)
+	}
+
+	for _, configDir := range configDirs {
+		entries, err := ioutil.ReadDir(configDir)
+		if err != nil {
+			return fmt.Errorf("reading '%s': %w", configDir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			ext := filepath.Ext(entry.Name())
+			if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				continue
+			}
+
+			filePath := filepath.Join(configDir, entry.Name())
+			if err := migrateFile(filePath, opts.from, to, opts.dryRun, opts.backup); err != nil {
+				return fmt.Errorf("%s: %w", filePath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// findConfigDirs walks the component tree rooted at componentPath and
+// returns every directory named "config", so migrate upgrades the invoking
+// component's own config as well as every subcomponent's, not just
+// componentPath/config.
+func findConfigDirs(componentPath string) ([]string, error) {
+	var configDirs []string
+
+	err := filepath.Walk(componentPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "config" {
+			configDirs = append(configDirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return configDirs, nil
+}
+
+func migrateFile(path, from, to string, dryRun, backup bool) (err error) {
+	originalBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	isJSON := strings.EqualFold(filepath.Ext(path), ".json")
+
+	doc := map[string]interface{}{}
+	if isJSON {
+		err = json.Unmarshal(originalBytes, &doc)
+	} else {
+		err = yamlv3.Unmarshal(originalBytes, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("decoding: %w", err)
+	}
+
+	detectedFrom := from
+	if detectedFrom == "" {
+		if apiVersion, ok := doc["apiVersion"].(string); ok {
+			detectedFrom = apiVersion
+		}
+	}
+
+	chain, err := migrations.Chain(detectedFrom, to)
+	if err != nil {
+		return err
+	}
+
+	if len(chain) == 0 {
+		return nil
+	}
+
+	for _, migrateFn := range chain {
+		doc, err = migrateFn(doc)
+		if err != nil {
+			return fmt.Errorf("migrating: %w", err)
+		}
+	}
+	doc["apiVersion"] = to
+
+	if err := migrations.Validate(to, doc); err != nil {
+		return fmt.Errorf("validating migrated config: %w", err)
+	}
+
+	var migratedBytes []byte
+	if isJSON {
+		migratedBytes, err = json.MarshalIndent(doc, "", "  ")
+	} else {
+		migratedBytes, err = marshalPreservingComments(originalBytes, doc)
+	}
+	if err != nil {
+		return fmt.Errorf("encoding: %w", err)
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", path, path)
+	for _, line := range diffLines(string(originalBytes), string(migratedBytes)) {
+		fmt.Println(line)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if backup {
+		if err := ioutil.WriteFile(path+".bak", originalBytes, 0644); err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+	}
+
+	return ioutil.WriteFile(path, migratedBytes, 0644)
+}
+
+// nodeComments is the head/line/foot comments yaml.Node attaches around a
+// mapping entry. key holds the comments yaml.Node puts on the key itself
+// (typically a standalone comment on the line above); value holds the
+// comments it puts on the value (typically a trailing "key: value # foo"
+// comment on the same line).
+type nodeComments struct {
+	key   commentTriplet
+	value commentTriplet
+}
+
+type commentTriplet struct {
+	head string
+	line string
+	foot string
+}
+
+func commentTripletOf(node *yamlv3.Node) commentTriplet {
+	return commentTriplet{head: node.HeadComment, line: node.LineComment, foot: node.FootComment}
+}
+
+func applyCommentTriplet(node *yamlv3.Node, c commentTriplet) {
+	node.HeadComment = c.head
+	node.LineComment = c.line
+	node.FootComment = c.foot
+}
+
+// marshalPreservingComments re-encodes the migrated doc as YAML, then
+// reattaches every comment present in originalBytes to the keys that still
+// exist at the same dotted path. Migrations only move or drop a handful of
+// keys (see core/migrations), so most paths are untouched and keep their
+// original comments; a key a migration introduced is left uncommented, and
+// a key it removed simply drops its comment along with it. This is why
+// this file decodes via gopkg.in/yaml.v3 directly rather than the repo's
+// usual github.com/timfpark/yaml alias: v3's yaml.Node is what exposes
+// comments at all.
+func marshalPreservingComments(originalBytes []byte, doc map[string]interface{}) ([]byte, error) {
+	var original yamlv3.Node
+	if err := yamlv3.Unmarshal(originalBytes, &original); err != nil {
+		return nil, fmt.Errorf("re-reading original for comments: %w", err)
+	}
+
+	comments := map[string]nodeComments{}
+	collectComments(&original, "", comments)
+
+	migratedBytes, err := yamlv3.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated yamlv3.Node
+	if err := yamlv3.Unmarshal(migratedBytes, &migrated); err != nil {
+		return nil, fmt.Errorf("re-reading migrated doc: %w", err)
+	}
+	applyComments(&migrated, "", comments)
+
+	return yamlv3.Marshal(&migrated)
+}
+
+// collectComments walks node, recording the head/line/foot comments on
+// every mapping key under its dotted path.
+func collectComments(node *yamlv3.Node, prefix string, out map[string]nodeComments) {
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, child := range node.Content {
+			collectComments(child, prefix, out)
+		}
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			path := keyNode.Value
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			out[path] = nodeComments{key: commentTripletOf(keyNode), value: commentTripletOf(valueNode)}
+			collectComments(valueNode, path, out)
+		}
+	}
+}
+
+// applyComments walks node, restoring any comment recorded in comments for
+// the dotted path of each mapping key found.
+func applyComments(node *yamlv3.Node, prefix string, comments map[string]nodeComments) {
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yamlv3.DocumentNode:
+		for _, child := range node.Content {
+			applyComments(child, prefix, comments)
+		}
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			path := keyNode.Value
+			if prefix != "" {
+				path = prefix + "." + path
+			}
+			if c, ok := comments[path]; ok {
+				applyCommentTriplet(keyNode, c.key)
+				applyCommentTriplet(valueNode, c.value)
+			}
+			applyComments(valueNode, path, comments)
+		}
+	}
+}
+
+// diffLines returns a minimal unified-diff-style rendering of the line-level
+// differences between original and migrated, via a longest-common-
+// subsequence alignment.
+func diffLines(original, migrated string) []string {
+	oldLines := strings.Split(strings.TrimRight(original, "\n"), "\n")
+	newLines := strings.Split(strings.TrimRight(migrated, "\n"), "\n")
+
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, " "+oldLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+"+newLines[j])
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff = append(diff, "-"+oldLines[i])
+	}
+	for ; j < len(newLines); j++ {
+		diff = append(diff, "+"+newLines[j])
+	}
+
+	return diff
+}
+
+func newMigrateCmd() *cobra.Command {
+	opts := migrateCmdOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrades config/*.yaml files in place between versioned ComponentConfig schemas.",
+		Long: `Upgrades config/*.yaml (and config/*.json) files between versioned ComponentConfig schemas, for the current component and every subcomponent beneath it, printing a unified diff of every change. YAML comments are preserved wherever the key they're attached to survives the migration.
+eg.
+$ fab config migrate
+
+Migrates every config file in the component tree to the current schema version (` + migrations.CurrentVersion + `).
+
+$ fab config migrate --dry-run
+
+Shows what would change without writing anything.
+
+$ fab config migrate --from fabrikate.io/v1 --to fabrikate.io/v2 --backup
+
+Migrates explicitly between two versions, writing a '.bak' copy of each file before overwriting it.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return migrate(".", opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.from, "from", "", "Schema version to migrate from (default: auto-detected from each file's apiVersion)")
+	cmd.Flags().StringVar(&opts.to, "to", "", fmt.Sprintf("Schema version to migrate to (default: %s)", migrations.CurrentVersion))
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the diff that would be applied without writing any files")
+	cmd.Flags().BoolVar(&opts.backup, "backup", false, "Write a '.bak' copy of each file before overwriting it")
+
+	return cmd
+}
+
+func init() {
+	configCmd.AddCommand(newMigrateCmd())
+}
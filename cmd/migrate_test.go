@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMigrateHoistsNamespaceAndPreservesComments runs a real end-to-end
+// migration (the legacy schema's hoistNamespace) over a commented YAML
+// fixture and asserts that namespace is hoisted to the top level, the
+// comments on keys that survive the migration are preserved, and the
+// printed diff reports the expected additions/removals.
+func TestMigrateHoistsNamespaceAndPreservesComments(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "config")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const original = `# top-level component comment
+config:
+  # the namespace this component lives in
+  namespace: my-ns
+  replicas: 3 # inline comment on replicas
+`
+	path := filepath.Join(configDir, "common.yaml")
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := captureStdout(t, func() {
+		if err := migrate(dir, migrateCmdOpts{}); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+	})
+
+	if !strings.Contains(stdout, "+apiVersion: fabrikate.io/v1") {
+		t.Errorf("expected diff to show apiVersion being added, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "-  namespace: my-ns") {
+		t.Errorf("expected diff to show the nested namespace line being removed, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "+namespace: my-ns") {
+		t.Errorf("expected diff to show namespace hoisted to the top level, got:\n%s", stdout)
+	}
+
+	migratedBytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated := string(migratedBytes)
+
+	if !strings.Contains(migrated, "apiVersion: fabrikate.io/v1") {
+		t.Errorf("expected migrated file to declare apiVersion, got:\n%s", migrated)
+	}
+	if !strings.Contains(migrated, "namespace: my-ns") {
+		t.Errorf("expected migrated file to hoist namespace to the top level, got:\n%s", migrated)
+	}
+	if strings.Contains(migrated, "config:\n  namespace") {
+		t.Errorf("expected namespace to no longer live under config, got:\n%s", migrated)
+	}
+
+	if !strings.Contains(migrated, "# top-level component comment") {
+		t.Errorf("expected the untouched top-level comment to survive migration, got:\n%s", migrated)
+	}
+	if !strings.Contains(migrated, "replicas: 3 # inline comment on replicas") {
+		t.Errorf("expected the untouched replicas line and its inline comment to survive migration, got:\n%s", migrated)
+	}
+}
+
+// captureStdout redirects os.Stdout for the duration of fn and returns
+// everything written to it; migrate prints its diff directly to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = original }()
+
+	fn()
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 0, 4096)
+	for {
+		chunk := make([]byte, 4096)
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			break
+		}
+	}
+
+	return string(buf)
+}
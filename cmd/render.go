@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/microsoft/fabrikate/core"
+	yaml "github.com/timfpark/yaml"
+
+	"github.com/spf13/cobra"
+)
+
+func render(environment string, flagOverrides map[string]bool) (err error) {
+	componentConfig := core.NewComponentConfig(".")
+	componentConfig.FlagOverrides = flagOverrides
+
+	if err := componentConfig.Load(environment); err != nil {
+		return err
+	}
+
+	scope := core.TemplateScope{Environment: environment, Namespace: componentConfig.Namespace, Version: Version}
+	if err := componentConfig.Render(scope); err != nil {
+		return err
+	}
+
+	rendered, err := yaml.Marshal(componentConfig)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(rendered))
+	return nil
+}
+
+func newRenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render <environment>",
+		Short: "Prints the fully Go-template-expanded config tree for an environment to stdout.",
+		Long: `Prints the fully Go-template-expanded config tree for an environment to stdout, for debugging templated config values before running 'fab install' or 'fab generate'.
+eg.
+$ fab render prod
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("'render' takes the target environment as its only argument")
+			}
+
+			cliFlags, err := cmd.Flags().GetStringArray("flag")
+			if err != nil {
+				return err
+			}
+			overrides, err := flagOverrides(cliFlags)
+			if err != nil {
+				return err
+			}
+
+			return render(args[0], overrides)
+		},
+	}
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newRenderCmd())
+}
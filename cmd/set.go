@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Set sets one or more config values for a component for a particular config
+// environment. When ref is non-empty, keyValues must contain exactly one
+// config path (no "=value" suffix); the path is set to a Reference parsed
+// from ref instead of a literal string, so the referenced secret or
+// environment value is never written to config/*.yaml.
+func Set(environment, subcomponent string, keyValues []string, useRef bool, ref string) (err error) {
+	// Load config
+	componentConfig := core.NewComponentConfig(".")
+
+	// Split component path delimited on "."
+	subcomponentPath := []string{}
+	if len(subcomponent) > 0 {
+		subcomponentPath = strings.Split(subcomponent, ".")
+	}
+
+	// Load target env config
+	if err := componentConfig.Load(environment); err != nil {
+		return err
+	}
+
+	if useRef {
+		if len(keyValues) != 1 {
+			return errors.New("'--ref' can only be used to set a single config key at a time")
+		}
+
+		reference, err := core.ParseReference(ref)
+		if err != nil {
+			return err
+		}
+
+		keyPath := strings.Split(keyValues[0], ".")
+		if err := componentConfig.SetConfigRef(subcomponentPath, keyPath, *reference); err != nil {
+			return err
+		}
+
+		log.Info(emoji.Sprintf(":key: Set '%s' to a reference", keyValues[0]))
+	} else {
+		for _, keyValue := range keyValues {
+			parts := strings.SplitN(keyValue, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("'%s' is not a valid key=value pair", keyValue)
+			}
+
+			keyPath := strings.Split(parts[0], ".")
+			if err := componentConfig.SetConfig(subcomponentPath, keyPath, parts[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Write out the config
+	return componentConfig.Write(environment)
+}
+
+type setCmdOpts struct {
+	subcomponent string
+	environment  string
+	ref          string
+}
+
+func newSetCmd() *cobra.Command {
+	opts := &setCmdOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "set [--subcomponent subcomponent] <key>=<value> ...",
+		Short: "Sets a config value for a component for a particular config environment in the Fabrikate definition.",
+		Long: `Sets a config value for a component for a particular config environment in the Fabrikate definition.
+eg.
+$ fab set --environment prod data.replicas=3
+
+Sets the key 'data.replicas' to '3' in the 'prod' config for the current component.
+
+$ fab set --subcomponent "myapp" endpoint=http://myapp
+
+Sets the key 'endpoint' in the 'common' config (the default) for subcomponent 'myapp'.
+
+$ fab set db.password --ref env:DB_PASSWORD
+
+Sets 'db.password' to a reference that is resolved from the 'DB_PASSWORD' environment variable at install/generate time, rather than writing the secret value into config/common.yaml. Supported ref sources are 'env:', 'file:', 'keyvault:<vault>/<name>', and 'k8s:<namespace>/<name>/<key>'.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("'set' takes one or more key=value pairs (or a single key when --ref is used)")
+			}
+
+			return Set(opts.environment, opts.subcomponent, args, opts.ref != "", opts.ref)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&opts.environment, "environment", "common", "Environment this configuration should be set for")
+	cmd.PersistentFlags().StringVar(&opts.subcomponent, "subcomponent", "", "Subcomponent this configuration should be set for")
+	cmd.PersistentFlags().StringVar(&opts.ref, "ref", "", "Set the value as a reference to an externally-stored secret rather than a literal value")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newSetCmd())
+}
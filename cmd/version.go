@@ -19,6 +19,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Version is the current version of Fabrikate being used. It is also the
+// value substituted for {{ .Version }} in rendered config templates (see
+// core.TemplateScope).
+const Version = "0.16.2"
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "The version of Fabrikate being used",
@@ -30,7 +35,7 @@ var versionCmd = &cobra.Command{
 
 // PrintVersion prints the current version of Fabrikate being used.
 func PrintVersion() {
-	logger.Info("fab version 0.16.2")
+	logger.Info("fab version " + Version)
 }
 
 func init() {
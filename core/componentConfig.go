@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -12,16 +13,54 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/timfpark/conjungo"
 	yaml "github.com/timfpark/yaml"
+
+	"github.com/microsoft/fabrikate/core/expr"
 )
 
 // ComponentConfig documentation: https://github.com/microsoft/fabrikate/blob/master/docs/config.md
 type ComponentConfig struct {
-	Path            string                     `yaml:"-" json:"-"`
-	Serialization   string                     `yaml:"-" json:"-"`
+	Path          string `yaml:"-" json:"-"`
+	Serialization string `yaml:"-" json:"-"`
+
+	// APIVersion marks the schema this config file was written against,
+	// e.g. "fabrikate.io/v1"; a missing/empty value means the legacy,
+	// pre-apiVersion schema. `fab config migrate` upgrades files between
+	// schema versions registered in core/migrations.
+	APIVersion      string                     `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
 	Namespace       string                     `yaml:"namespace,omitempty" json:"namespace,omitempty"`
 	InjectNamespace bool                       `yaml:"injectNamespace,omitempty" json:"injectNamespace,omitempty"`
 	Config          map[string]interface{}     `yaml:"config,omitempty" json:"config,omitempty"`
 	Subcomponents   map[string]ComponentConfig `yaml:"subcomponents,omitempty" json:"subcomponents,omitempty"`
+
+	// Flags declares default feature-flag values for this component, e.g.
+	// `flags: {telemetry: true, ha: false}`. Defaults are overridable via
+	// FlagOverrides (wired up from `--flag name=value` / `FAB_FLAG_NAME`).
+	Flags map[string]bool `yaml:"flags,omitempty" json:"flags,omitempty"`
+
+	// When, if set, is a core/expr expression; if it evaluates false this
+	// entire component (and its Config/Subcomponents) is pruned during Load.
+	When string `yaml:"when,omitempty" json:"when,omitempty"`
+
+	// FlagOverrides is never persisted; it lets callers (e.g. the `--flag`
+	// CLI flag) override Flags' defaults before Load evaluates `when`
+	// clauses.
+	FlagOverrides map[string]bool `yaml:"-" json:"-"`
+
+	// CLISets holds `--set a.b.c=value` overrides supplied by the caller;
+	// consumed as the highest-priority layer by LoadLayers.
+	CLISets []string `yaml:"-" json:"-"`
+
+	// Provenance records, for every dotted Config path, the name of the
+	// layer ("system", "home", "project", "env", or "cli") that supplied its
+	// effective value. Populated only by LoadLayers.
+	Provenance map[string]string `yaml:"-" json:"-"`
+
+	// projectLayer is the layer (3) ComponentConfig as loaded from this
+	// component's own config/<env> file, before the lower-priority system/
+	// home layers or the higher-priority env/CLI layers were merged in.
+	// Write persists this instead of the merged result, since only layer
+	// (3) is ever meant to be written back to disk.
+	projectLayer *ComponentConfig `yaml:"-" json:"-"`
 }
 
 // NewComponentConfig creates a ComponentConfig at the passed path.
@@ -62,23 +101,149 @@ func (cc *ComponentConfig) MergeConfigFile(path string, environment string) (err
 	return cc.Merge(componentConfig)
 }
 
-// Load loads the config for the specified environment.
+// Load loads the config for the specified environment, then evaluates and
+// strips any `flags`/`when` conditionals found in the tree.
 func (cc *ComponentConfig) Load(environment string) (err error) {
-	err = cc.UnmarshalYAMLConfig(environment)
+	cc.unmarshalConfigFile(environment)
 
-	// fall back to looking for JSON if loading YAML fails.
-	if err != nil {
-		err = cc.UnmarshalJSONConfig(environment)
+	return cc.applyConditionals(environment, expr.Context{Environment: environment, Namespace: cc.Namespace})
+}
 
-		if err != nil {
-			// couldn't find any config files, so default back to yaml serialization
+// unmarshalConfigFile reads this component's config/<environment> file
+// without evaluating any `flags`/`when` conditionals, falling back to JSON
+// if YAML fails to parse and defaulting to YAML serialization if neither is
+// found. This is split out of Load so LoadLayers' per-layer loaders (e.g.
+// loadProjectLayer) can read a layer's raw config without pruning it: a
+// `when` clause must only ever be evaluated once, against the fully merged
+// Flags from every layer, not against a single layer's partial view of them.
+func (cc *ComponentConfig) unmarshalConfigFile(environment string) {
+	if err := cc.UnmarshalYAMLConfig(environment); err != nil {
+		if err := cc.UnmarshalJSONConfig(environment); err != nil {
 			cc.Serialization = "yaml"
 		}
 	}
+}
+
+// applyConditionals evaluates this component's `when` clause (if any, with
+// ctx itself deciding whether it survives), resolves its effective flags
+// (Flags defaults overridden by FlagOverrides), filters `when`-guarded
+// subtrees out of Config, and recurses into surviving Subcomponents.
+func (cc *ComponentConfig) applyConditionals(environment string, ctx expr.Context) (err error) {
+	flags := map[string]bool{}
+	for name, value := range cc.Flags {
+		flags[name] = value
+	}
+	for name, value := range cc.FlagOverrides {
+		flags[name] = value
+	}
+	ctx.Flags = flags
+	// Evaluate "when" clauses against a frozen snapshot of Config, taken
+	// before any pruning happens: filterConditional deletes keys from the
+	// live map as it walks it, and Go map iteration order is unspecified,
+	// so a "when" that references a sibling key (e.g. "config.b.enabled")
+	// must not see a partially-pruned map or its result would depend on
+	// iteration order.
+	ctx.Config = deepCopyConfigMap(cc.Config)
+
+	if cc.Config != nil {
+		filtered, err := filterConditional(cc.Config, ctx)
+		if err != nil {
+			return err
+		}
+		cc.Config = filtered
+	}
+
+	for name, subcomponentConfig := range cc.Subcomponents {
+		subcomponentConfig.FlagOverrides = cc.FlagOverrides
+		keep, err := shouldKeep(subcomponentConfig.When, ctx)
+		if err != nil {
+			return fmt.Errorf("subcomponent '%s': %w", name, err)
+		}
+		if !keep {
+			delete(cc.Subcomponents, name)
+			continue
+		}
+
+		if err := subcomponentConfig.applyConditionals(environment, ctx); err != nil {
+			return fmt.Errorf("subcomponent '%s': %w", name, err)
+		}
+		cc.Subcomponents[name] = subcomponentConfig
+	}
 
 	return nil
 }
 
+// shouldKeep reports whether a `when` clause (empty means "always") passes
+// against ctx.
+func shouldKeep(when string, ctx expr.Context) (bool, error) {
+	if when == "" {
+		return true, nil
+	}
+
+	condition, err := expr.Parse(when)
+	if err != nil {
+		return false, fmt.Errorf("parsing 'when' expression '%s': %w", when, err)
+	}
+
+	return expr.Eval(condition, ctx)
+}
+
+// filterConditional walks a raw config map, evaluating any "when" clause
+// found at this level or nested levels and dropping maps whose condition is
+// false. The "when" key itself is always stripped from the result.
+func filterConditional(node map[string]interface{}, ctx expr.Context) (map[string]interface{}, error) {
+	if rawWhen, ok := node["when"]; ok {
+		whenStr, ok := rawWhen.(string)
+		if !ok {
+			return nil, fmt.Errorf("'when' must be a string expression")
+		}
+		delete(node, "when")
+
+		keep, err := shouldKeep(whenStr, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			return nil, nil
+		}
+	}
+
+	for key, value := range node {
+		if nested, ok := value.(map[string]interface{}); ok {
+			filtered, err := filterConditional(nested, ctx)
+			if err != nil {
+				return nil, err
+			}
+			if filtered == nil {
+				delete(node, key)
+			} else {
+				node[key] = filtered
+			}
+		}
+	}
+
+	return node, nil
+}
+
+// deepCopyConfigMap recursively copies a config map so a snapshot of it can
+// be used as evaluation context while the original is mutated elsewhere.
+func deepCopyConfigMap(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		if nested, ok := value.(map[string]interface{}); ok {
+			copied[key] = deepCopyConfigMap(nested)
+		} else {
+			copied[key] = value
+		}
+	}
+
+	return copied
+}
+
 // HasComponentConfig checks if the component contains the given component configuration.
 // The given component is specified via a configuration `path`.
 // Returns true if it contains it, otherwise it returns false.
@@ -135,6 +300,34 @@ func (cc *ComponentConfig) SetComponentConfig(path []string, value string) (err
 	return err
 }
 
+// SetComponentConfigRef sets the value at `path` to a Reference rather than
+// a literal string, so the secret or environment-sourced value it points at
+// is never written to config/*.yaml. It walks the tree identically to
+// SetComponentConfig, creating intermediate maps as needed.
+func (cc *ComponentConfig) SetComponentConfigRef(path []string, ref Reference) (err error) {
+	configLevel := cc.Config
+
+	for levelIndex, pathPart := range path {
+		if levelIndex < len(path)-1 {
+			if _, ok := configLevel[pathPart]; !ok {
+				configLevel[pathPart] = map[string]interface{}{}
+			}
+
+			if _, isAMap := configLevel[pathPart].(map[string]interface{}); !isAMap {
+				currentPath := strings.Join(path[:levelIndex+1], ".")
+				fullPath := strings.Join(path, ".")
+				return fmt.Errorf("Config path '%s' points to a non-map value; cannot set '%s' to a reference", currentPath, fullPath)
+			}
+
+			configLevel = configLevel[pathPart].(map[string]interface{})
+		} else {
+			configLevel[pathPart] = ref.asConfigNode()
+		}
+	}
+
+	return err
+}
+
 // UnsetComponentConfig unsets a key from a component config (deleteing the key
 // from the map). If any of the keys provided in `keyPath` are not found, this
 // is treated as a noop.
@@ -256,6 +449,13 @@ func (cc *ComponentConfig) SetConfig(subcomponentPath []string, path []string, v
 	return err
 }
 
+// SetConfigRef sets or creates a reference to an externally-stored value for
+// the given `subcomponentPath`. See SetComponentConfigRef.
+func (cc *ComponentConfig) SetConfigRef(subcomponentPath []string, path []string, ref Reference) (err error) {
+	subcomponentConfig := cc.GetSubcomponentConfig(subcomponentPath)
+	return subcomponentConfig.SetComponentConfigRef(path, ref)
+}
+
 // UnsetConfig removes a key from a the target subcomponent config
 func (cc *ComponentConfig) UnsetConfig(subcomponentPath []string, path []string) error {
 	subcomponentConfig := cc.GetSubcomponentConfig(subcomponentPath)
@@ -279,6 +479,9 @@ func (cc *ComponentConfig) MergeNamespaces(newConfig ComponentConfig) ComponentC
 
 // Merge merges the config (and the namespace spec) between the passed componentConfig
 // and this componentConfig.  In the case of conflicts, this componentConfig wins.
+// Reference values are merged like any other leaf value, so a winning
+// Reference is carried over untouched rather than being resolved or merged
+// field-by-field with a losing one.
 func (cc *ComponentConfig) Merge(newConfig ComponentConfig) (err error) {
 	options := conjungo.NewOptions()
 	options.Overwrite = false
@@ -290,23 +493,78 @@ func (cc *ComponentConfig) Merge(newConfig ComponentConfig) (err error) {
 	return err
 }
 
+// Resolve walks Config and Subcomponents, dispatching every Reference node
+// it finds to resolver and substituting the resolved value in its place.
+// Resolution only ever happens in-memory; Write must be called, if at all,
+// before Resolve so that resolved secret material is never persisted back
+// to config/*.yaml.
+func (cc *ComponentConfig) Resolve(ctx context.Context, resolver ReferenceResolver) (err error) {
+	resolved, err := resolveConfigMap(ctx, resolver, cc.Config)
+	if err != nil {
+		return err
+	}
+	cc.Config = resolved
+
+	for name, subcomponentConfig := range cc.Subcomponents {
+		if err := subcomponentConfig.Resolve(ctx, resolver); err != nil {
+			return err
+		}
+		cc.Subcomponents[name] = subcomponentConfig
+	}
+
+	return nil
+}
+
+// resolveConfigMap recursively replaces Reference nodes within a config map
+// with their resolved values, leaving literal values untouched.
+func resolveConfigMap(ctx context.Context, resolver ReferenceResolver, config map[string]interface{}) (map[string]interface{}, error) {
+	for key, value := range config {
+		if ref, ok := asReference(value); ok {
+			resolvedValue, err := resolver.Resolve(ctx, *ref)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve reference for '%s': %w", key, err)
+			}
+			config[key] = resolvedValue
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			resolvedNested, err := resolveConfigMap(ctx, resolver, nested)
+			if err != nil {
+				return nil, err
+			}
+			config[key] = resolvedNested
+		}
+	}
+
+	return config, nil
+}
+
 // Write writes this componentConfig to a file using the serialization specified in
-// cc.Serialization.
+// cc.Serialization. If this ComponentConfig was produced by LoadLayers, only
+// the project layer (config/<env>.<serialization>, today's behavior) is
+// persisted; the system, home, env, and CLI layers it was merged with are
+// read-only and are never written back.
 func (cc *ComponentConfig) Write(environment string) (err error) {
+	toWrite := cc
+	if cc.projectLayer != nil {
+		toWrite = cc.projectLayer
+	}
+
 	var marshaledConfig []byte
 
-	_ = os.Mkdir(cc.Path, os.ModePerm)
-	_ = os.Mkdir(path.Join(cc.Path, "config"), os.ModePerm)
+	_ = os.Mkdir(toWrite.Path, os.ModePerm)
+	_ = os.Mkdir(path.Join(toWrite.Path, "config"), os.ModePerm)
 
-	if cc.Serialization == "json" {
-		marshaledConfig, err = json.MarshalIndent(cc, "", "  ")
+	if toWrite.Serialization == "json" {
+		marshaledConfig, err = json.MarshalIndent(toWrite, "", "  ")
 	} else {
-		marshaledConfig, err = yaml.Marshal(cc)
+		marshaledConfig, err = yaml.Marshal(toWrite)
 	}
 
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(cc.GetPath(environment), marshaledConfig, 0644)
+	return ioutil.WriteFile(toWrite.GetPath(environment), marshaledConfig, 0644)
 }
@@ -0,0 +1,164 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Context is the data a `when` expression is evaluated against: the
+// component's resolved feature flags, the active environment and
+// namespace, and its config tree (for paths like "config.region").
+type Context struct {
+	Flags       map[string]bool
+	Environment string
+	Namespace   string
+	Config      map[string]interface{}
+}
+
+// Eval evaluates expression against ctx and returns its truthiness. An
+// error is returned if the expression references an unknown path or
+// compares incompatible types.
+func Eval(expression Expr, ctx Context) (bool, error) {
+	value, err := evalValue(expression, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	asBool, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression did not evaluate to a boolean: %v", value)
+	}
+
+	return asBool, nil
+}
+
+func evalValue(expression Expr, ctx Context) (interface{}, error) {
+	switch e := expression.(type) {
+	case BoolLit:
+		return e.Value, nil
+	case StringLit:
+		return e.Value, nil
+	case NumberLit:
+		return e.Value, nil
+	case Path:
+		return resolvePath(e.Parts, ctx)
+	case UnaryExpr:
+		operand, err := evalValue(e.Operand, ctx)
+		if err != nil {
+			return nil, err
+		}
+		asBool, ok := operand.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'!' requires a boolean operand, got %v", operand)
+		}
+		return !asBool, nil
+	case BinaryExpr:
+		return evalBinary(e, ctx)
+	default:
+		return nil, fmt.Errorf("unsupported expression node %T", expression)
+	}
+}
+
+func evalBinary(e BinaryExpr, ctx Context) (interface{}, error) {
+	switch e.Op {
+	case "&&", "||":
+		left, err := evalValue(e.Left, ctx)
+		if err != nil {
+			return nil, err
+		}
+		leftBool, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'%s' requires boolean operands, got %v", e.Op, left)
+		}
+
+		// Short-circuit without evaluating the right-hand side.
+		if e.Op == "&&" && !leftBool {
+			return false, nil
+		}
+		if e.Op == "||" && leftBool {
+			return true, nil
+		}
+
+		right, err := evalValue(e.Right, ctx)
+		if err != nil {
+			return nil, err
+		}
+		rightBool, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'%s' requires boolean operands, got %v", e.Op, right)
+		}
+		return rightBool, nil
+	case "==", "!=":
+		left, err := evalValue(e.Left, ctx)
+		if err != nil {
+			return nil, err
+		}
+		right, err := evalValue(e.Right, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if reflect.TypeOf(left) != reflect.TypeOf(right) {
+			return nil, fmt.Errorf("'%s' requires operands of the same type, got %T and %T", e.Op, left, right)
+		}
+		equal := left == right
+		if e.Op == "!=" {
+			return !equal, nil
+		}
+		return equal, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator '%s'", e.Op)
+	}
+}
+
+// resolvePath resolves a dotted path against ctx, e.g. "flags.ha",
+// "environment", "namespace", or "config.region"/"config.db.host".
+func resolvePath(parts []string, ctx Context) (interface{}, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	switch parts[0] {
+	case "environment":
+		return ctx.Environment, nil
+	case "namespace":
+		return ctx.Namespace, nil
+	case "flags":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("'flags' paths must be 'flags.<name>', got '%s'", joinPath(parts))
+		}
+		return ctx.Flags[parts[1]], nil
+	case "config":
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("'config' paths must be 'config.<key>', got '%s'", joinPath(parts))
+		}
+		return resolveConfigPath(parts[1:], ctx.Config)
+	default:
+		return nil, fmt.Errorf("unknown path root '%s'; expected one of flags, environment, namespace, config", parts[0])
+	}
+}
+
+func resolveConfigPath(parts []string, config map[string]interface{}) (interface{}, error) {
+	value, ok := config[parts[0]]
+	if !ok {
+		return nil, nil
+	}
+
+	if len(parts) == 1 {
+		return value, nil
+	}
+
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%s' does not point to a map; cannot resolve '%s'", parts[0], joinPath(parts))
+	}
+
+	return resolveConfigPath(parts[1:], nested)
+}
+
+func joinPath(parts []string) string {
+	result := parts[0]
+	for _, part := range parts[1:] {
+		result += "." + part
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package expr
+
+import "testing"
+
+func mustParse(t *testing.T, source string) Expr {
+	t.Helper()
+	expression, err := Parse(source)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", source, err)
+	}
+	return expression
+}
+
+func TestEvalFlagsAndEnvironment(t *testing.T) {
+	ctx := Context{Flags: map[string]bool{"ha": true}, Environment: "prod"}
+
+	ok, err := Eval(mustParse(t, "flags.ha && environment == 'prod'"), ctx)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected true")
+	}
+}
+
+func TestEvalShortCircuitsAnd(t *testing.T) {
+	// The right-hand side references an unknown path root and would error
+	// if evaluated; && must short-circuit on a false left-hand side
+	// without evaluating it.
+	ctx := Context{Flags: map[string]bool{"ha": false}}
+
+	ok, err := Eval(mustParse(t, "flags.ha && bogus.path"), ctx)
+	if err != nil {
+		t.Fatalf("expected short-circuit to avoid the right-hand side error, got: %v", err)
+	}
+	if ok {
+		t.Fatal("expected false")
+	}
+}
+
+func TestEvalShortCircuitsOr(t *testing.T) {
+	ctx := Context{Flags: map[string]bool{"ha": true}}
+
+	ok, err := Eval(mustParse(t, "flags.ha || bogus.path"), ctx)
+	if err != nil {
+		t.Fatalf("expected short-circuit to avoid the right-hand side error, got: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected true")
+	}
+}
+
+func TestEvalConfigPath(t *testing.T) {
+	ctx := Context{Config: map[string]interface{}{"region": "us-east-1"}}
+
+	ok, err := Eval(mustParse(t, "config.region == 'us-east-1'"), ctx)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected true")
+	}
+}
+
+func TestEvalUnknownPathRootErrors(t *testing.T) {
+	if _, err := Eval(mustParse(t, "bogus"), Context{}); err == nil {
+		t.Fatal("expected an error for an unknown path root")
+	}
+}
+
+func TestEvalNonBooleanResultErrors(t *testing.T) {
+	if _, err := Eval(mustParse(t, "'prod'"), Context{}); err == nil {
+		t.Fatal("expected an error since the expression doesn't evaluate to a boolean")
+	}
+}
+
+// TestEvalComparingIncompatibleTypesErrors is a regression test: "==" and
+// "!=" must error on operands of different types rather than silently
+// evaluating to false, per Eval's documented behavior.
+func TestEvalComparingIncompatibleTypesErrors(t *testing.T) {
+	ctx := Context{Flags: map[string]bool{"ha": true}, Environment: "5"}
+
+	if _, err := Eval(mustParse(t, "flags.ha == environment"), ctx); err == nil {
+		t.Fatal("expected an error comparing a bool to a string")
+	}
+
+	if _, err := Eval(mustParse(t, "5 == 'five'"), Context{}); err == nil {
+		t.Fatal("expected an error comparing a number to a string")
+	}
+}
+
+func TestEvalNotEqual(t *testing.T) {
+	ctx := Context{Environment: "staging"}
+
+	ok, err := Eval(mustParse(t, "environment != 'prod'"), ctx)
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected true")
+	}
+}
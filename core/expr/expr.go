@@ -0,0 +1,301 @@
+// Package expr implements a small boolean expression language used to
+// evaluate `when:` clauses in ComponentConfig, e.g.
+// `flags.ha && environment == 'prod'`. It supports identifiers and dotted
+// paths, string/bool/number literals, and the operators `!`, `&&`, `||`,
+// `==`, and `!=`, with `(` `)` for grouping.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed node in a `when` expression.
+type Expr interface {
+	isExpr()
+}
+
+// Path is a dotted identifier chain, e.g. "flags.ha" or "config.region".
+type Path struct {
+	Parts []string
+}
+
+// BoolLit is a literal `true`/`false`.
+type BoolLit struct {
+	Value bool
+}
+
+// StringLit is a single- or double-quoted string literal.
+type StringLit struct {
+	Value string
+}
+
+// NumberLit is a numeric literal.
+type NumberLit struct {
+	Value float64
+}
+
+// UnaryExpr is a `!` applied to Operand.
+type UnaryExpr struct {
+	Op      string
+	Operand Expr
+}
+
+// BinaryExpr is Left Op Right for Op in {&&, ||, ==, !=}.
+type BinaryExpr struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+func (Path) isExpr()       {}
+func (BoolLit) isExpr()    {}
+func (StringLit) isExpr()  {}
+func (NumberLit) isExpr()  {}
+func (UnaryExpr) isExpr()  {}
+func (BinaryExpr) isExpr() {}
+
+// Parse parses a `when` expression into an Expr tree.
+func Parse(source string) (Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expression, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token '%s' in expression '%s'", p.tokens[p.pos].text, source)
+	}
+
+	return expression, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")"})
+			i++
+		case c == '!' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{tokenOp, "!="})
+			i += 2
+		case c == '!':
+			tokens = append(tokens, token{tokenOp, "!"})
+			i++
+		case c == '=' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{tokenOp, "=="})
+			i += 2
+		case c == '&' && i+1 < len(runes) && runes[i+1] == '&':
+			tokens = append(tokens, token{tokenOp, "&&"})
+			i += 2
+		case c == '|' && i+1 < len(runes) && runes[i+1] == '|':
+			tokens = append(tokens, token{tokenOp, "||"})
+			i += 2
+		case c == '\'' || c == '"':
+			quote := c
+			j := i + 1
+			for j < len(runes) && runes[j] != quote {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string literal in expression '%s'", source)
+			}
+			tokens = append(tokens, token{tokenString, string(runes[i+1 : j])})
+			i = j + 1
+		case isIdentStart(c):
+			j := i
+			for j < len(runes) && isIdentPart(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[i:j])})
+			i = j
+		case isDigit(c):
+			j := i
+			for j < len(runes) && (isDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' in expression '%s'", c, source)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.'
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenOp || tok.text != "||" {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = BinaryExpr{Op: "||", Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenOp || tok.text != "&&" {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+		left = BinaryExpr{Op: "&&", Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseEquality() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok, ok := p.peek()
+	if !ok || tok.kind != tokenOp || (tok.text != "==" && tok.text != "!=") {
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	return BinaryExpr{Op: tok.text, Left: left, Right: right}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok, ok := p.peek()
+	if ok && tok.kind == tokenOp && tok.text == "!" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return UnaryExpr{Op: "!", Operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	switch tok.kind {
+	case tokenLParen:
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := p.peek()
+		if !ok || closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ')' in expression")
+		}
+		p.pos++
+		return inner, nil
+	case tokenString:
+		p.pos++
+		return StringLit{Value: tok.text}, nil
+	case tokenNumber:
+		p.pos++
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number literal '%s'", tok.text)
+		}
+		return NumberLit{Value: value}, nil
+	case tokenIdent:
+		p.pos++
+		switch tok.text {
+		case "true":
+			return BoolLit{Value: true}, nil
+		case "false":
+			return BoolLit{Value: false}, nil
+		default:
+			return Path{Parts: strings.Split(tok.text, ".")}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unexpected token '%s'", tok.text)
+	}
+}
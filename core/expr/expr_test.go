@@ -0,0 +1,71 @@
+package expr
+
+import "testing"
+
+func TestParsePrecedence(t *testing.T) {
+	// && binds tighter than ||, so "a || b && c" parses as "a || (b && c)".
+	expression, err := Parse("false || true && false")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	or, ok := expression.(BinaryExpr)
+	if !ok || or.Op != "||" {
+		t.Fatalf("expected top-level '||', got %#v", expression)
+	}
+
+	and, ok := or.Right.(BinaryExpr)
+	if !ok || and.Op != "&&" {
+		t.Fatalf("expected right-hand side to be the '&&' clause, got %#v", or.Right)
+	}
+}
+
+func TestParseEqualityBindsTighterThanAnd(t *testing.T) {
+	// "a == b && c == d" parses as "(a == b) && (c == d)", not
+	// "a == (b && c) == d" (equality is not chainable/associative here).
+	expression, err := Parse("environment == 'prod' && flags.ha")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	and, ok := expression.(BinaryExpr)
+	if !ok || and.Op != "&&" {
+		t.Fatalf("expected top-level '&&', got %#v", expression)
+	}
+
+	eq, ok := and.Left.(BinaryExpr)
+	if !ok || eq.Op != "==" {
+		t.Fatalf("expected left-hand side to be the '==' clause, got %#v", and.Left)
+	}
+}
+
+func TestParseNegationAndGrouping(t *testing.T) {
+	expression, err := Parse("!(flags.ha || flags.dr)")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	not, ok := expression.(UnaryExpr)
+	if !ok || not.Op != "!" {
+		t.Fatalf("expected top-level '!', got %#v", expression)
+	}
+	if _, ok := not.Operand.(BinaryExpr); !ok {
+		t.Fatalf("expected negated operand to be the grouped '||' clause, got %#v", not.Operand)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		"flags.ha &&",
+		"(flags.ha",
+		"'unterminated",
+		"flags.ha $ flags.dr",
+		"flags.ha flags.dr",
+	}
+
+	for _, source := range cases {
+		if _, err := Parse(source); err == nil {
+			t.Errorf("Parse(%q): expected an error, got nil", source)
+		}
+	}
+}
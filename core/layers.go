@@ -0,0 +1,220 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/timfpark/conjungo"
+	yaml "github.com/timfpark/yaml"
+
+	"github.com/microsoft/fabrikate/core/expr"
+)
+
+// configLayer names a single source in the precedence ladder LoadLayers
+// merges, from lowest to highest priority.
+type configLayer struct {
+	name string
+	load func(cc *ComponentConfig, environment string) (*ComponentConfig, error)
+}
+
+var configLayers = []configLayer{
+	{"system", (*ComponentConfig).loadSystemLayer},
+	{"home", (*ComponentConfig).loadHomeLayer},
+	{"project", (*ComponentConfig).loadProjectLayer},
+	{"env", (*ComponentConfig).loadEnvLayer},
+	{"cli", (*ComponentConfig).loadCLILayer},
+}
+
+// LoadLayers loads and merges configuration for environment from, in
+// increasing priority: (1) a system-wide file (/etc/fabrikate/<env>.yaml, or
+// %PROGRAMDATA%\fabrikate\<env>.yaml on Windows), (2) ~/.fabrikate/<env>.yaml,
+// (3) this component's own config/<env>.yaml (today's Load behavior), (4)
+// FAB_CONFIG__a__b__c-style environment variables, and (5) CLISets
+// (`--set a.b.c=value`). Each layer's leaves are recorded in Provenance.
+// Only layer (3) is ever written back to disk by Write.
+func (cc *ComponentConfig) LoadLayers(environment string) (err error) {
+	cc.Provenance = map[string]string{}
+	merged := NewComponentConfig(cc.Path)
+
+	for _, layer := range configLayers {
+		layerConfig, err := layer.load(cc, environment)
+		if err != nil {
+			return fmt.Errorf("loading %s config layer: %w", layer.name, err)
+		}
+		if layerConfig == nil {
+			continue
+		}
+
+		// Merge field-by-field rather than handing conjungo the whole
+		// ComponentConfig struct: ComponentConfig has an unexported field
+		// (projectLayer), and conjungo falls back to whole-value overwrite
+		// for any struct it can't merge field-by-field, which would make
+		// each layer replace merged outright instead of merging into it.
+		options := conjungo.NewOptions()
+		options.Overwrite = true
+		if err := conjungo.Merge(&merged.Config, layerConfig.Config, options); err != nil {
+			return fmt.Errorf("merging %s config layer: %w", layer.name, err)
+		}
+		for name, subcomponent := range layerConfig.Subcomponents {
+			merged.Subcomponents[name] = subcomponent
+		}
+		for name, value := range layerConfig.Flags {
+			if merged.Flags == nil {
+				merged.Flags = map[string]bool{}
+			}
+			merged.Flags[name] = value
+		}
+		merged.MergeNamespaces(*layerConfig)
+
+		recordProvenance(layerConfig.Config, "", layer.name, cc.Provenance)
+
+		if layer.name == "project" {
+			projectCopy := *layerConfig
+			cc.projectLayer = &projectCopy
+			merged.Serialization = layerConfig.Serialization
+		}
+	}
+
+	cc.Config = merged.Config
+	cc.Subcomponents = merged.Subcomponents
+	cc.Namespace = merged.Namespace
+	cc.InjectNamespace = merged.InjectNamespace
+	cc.Flags = merged.Flags
+	cc.Serialization = merged.Serialization
+
+	return cc.applyConditionals(environment, expr.Context{Environment: environment, Namespace: cc.Namespace})
+}
+
+// loadSystemLayer reads the system-wide config file for environment, or nil
+// if it does not exist.
+func (cc *ComponentConfig) loadSystemLayer(environment string) (*ComponentConfig, error) {
+	return loadLayerFile(filepath.Join(systemConfigDir(), environment+".yaml"))
+}
+
+// loadHomeLayer reads the current user's ~/.fabrikate config file for
+// environment, or nil if it does not exist (or there is no home directory).
+func (cc *ComponentConfig) loadHomeLayer(environment string) (*ComponentConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+
+	return loadLayerFile(filepath.Join(home, ".fabrikate", environment+".yaml"))
+}
+
+// loadProjectLayer reads this component's own config/<env> file; this is
+// the same file today's Load operates on. It intentionally reads the raw
+// file via unmarshalConfigFile rather than Load, so no `when` clause in it
+// is evaluated yet: a `when` clause must see every layer's Flags merged
+// together (e.g. a default declared only in the system or home layer), and
+// LoadLayers only has that complete picture after every layer below has
+// been merged into cc. Evaluating (and destructively pruning) conditionals
+// here, against only this layer's own Flags, could drop a subtree before
+// LoadLayers' single final applyConditionals pass ever saw the full set.
+func (cc *ComponentConfig) loadProjectLayer(environment string) (*ComponentConfig, error) {
+	project := NewComponentConfig(cc.Path)
+	project.unmarshalConfigFile(environment)
+	return &project, nil
+}
+
+// loadEnvLayer builds a ComponentConfig from FAB_CONFIG__a__b__c=value
+// environment variables, translating "__"-delimited suffixes into nested
+// Config keys.
+func (cc *ComponentConfig) loadEnvLayer(environment string) (*ComponentConfig, error) {
+	const envPrefix = "FAB_CONFIG__"
+
+	envLayer := NewComponentConfig(cc.Path)
+	found := false
+
+	for _, entry := range os.Environ() {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+
+		keyPath := strings.Split(strings.TrimPrefix(name, envPrefix), "__")
+		if err := envLayer.SetComponentConfig(keyPath, value); err != nil {
+			return nil, err
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &envLayer, nil
+}
+
+// loadCLILayer builds a ComponentConfig from `--set a.b.c=value` overrides
+// supplied via cc.CLISets.
+func (cc *ComponentConfig) loadCLILayer(environment string) (*ComponentConfig, error) {
+	if len(cc.CLISets) == 0 {
+		return nil, nil
+	}
+
+	cliLayer := NewComponentConfig(cc.Path)
+	for _, set := range cc.CLISets {
+		keyValue, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return nil, fmt.Errorf("'--set' values must be 'a.b.c=value', got '%s'", set)
+		}
+
+		if err := cliLayer.SetComponentConfig(strings.Split(keyValue, "."), value); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cliLayer, nil
+}
+
+// loadLayerFile reads and unmarshals a single config layer file (YAML,
+// falling back to JSON), returning nil if the file does not exist.
+func loadLayerFile(path string) (*ComponentConfig, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	layerConfig := NewComponentConfig(filepath.Dir(path))
+
+	if err := UnmarshalFile(path, yaml.Unmarshal, &layerConfig); err != nil {
+		if err := UnmarshalFile(path, json.Unmarshal, &layerConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	return &layerConfig, nil
+}
+
+// systemConfigDir returns the platform's system-wide fabrikate config
+// directory: /etc/fabrikate, or %PROGRAMDATA%\fabrikate on Windows.
+func systemConfigDir() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("PROGRAMDATA"), "fabrikate")
+	}
+	return "/etc/fabrikate"
+}
+
+// recordProvenance marks every string/map leaf path found in config as
+// having come from layerName, overwriting any provenance recorded by a
+// lower-priority layer for the same path.
+func recordProvenance(config map[string]interface{}, prefix, layerName string, provenance map[string]string) {
+	for key, value := range config {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			recordProvenance(nested, path, layerName, provenance)
+		} else {
+			provenance[path] = layerName
+		}
+	}
+}
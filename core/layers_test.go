@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadLayersHonorsFlagOverridesInProjectLayer is a regression test for a
+// bug where loadProjectLayer evaluated the project's own `when` clauses
+// using only its default `flags:` values, ignoring FlagOverrides: the
+// guarded subtree was pruned before LoadLayers' final applyConditionals
+// pass ever saw the CLI/env override, so `fab inspect --flag ha=true`
+// silently had no effect on `when` clauses living in the project's own
+// config file.
+func TestLoadLayersHonorsFlagOverridesInProjectLayer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const configYAML = `
+flags:
+  ha: false
+config:
+  cluster:
+    when: flags.ha
+    replicas: 5
+`
+	if err := os.WriteFile(filepath.Join(dir, "config", "test.yaml"), []byte(configYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	withoutOverride := NewComponentConfig(dir)
+	if err := withoutOverride.LoadLayers("test"); err != nil {
+		t.Fatalf("LoadLayers without override: %v", err)
+	}
+	if _, ok := withoutOverride.Config["cluster"]; ok {
+		t.Fatalf("expected 'cluster' to be pruned when flags.ha defaults to false, got %#v", withoutOverride.Config)
+	}
+
+	withOverride := NewComponentConfig(dir)
+	withOverride.FlagOverrides = map[string]bool{"ha": true}
+	if err := withOverride.LoadLayers("test"); err != nil {
+		t.Fatalf("LoadLayers with override: %v", err)
+	}
+	cluster, ok := withOverride.Config["cluster"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected 'cluster' to survive when --flag ha=true overrides the default, got %#v", withOverride.Config["cluster"])
+	}
+	if fmt.Sprintf("%v", cluster["replicas"]) != "5" {
+		t.Fatalf("expected cluster.replicas to be 5, got %v", cluster["replicas"])
+	}
+}
+
+// TestLoadLayersMergesFlagsAcrossLayersBeforeEvaluatingWhen is a regression
+// test for a bug where loadProjectLayer evaluated the project's own `when`
+// clauses against only its own `flags:` declarations, before the
+// lower-priority system/home layers had been merged in. A `flags:` default
+// declared only in the home layer (simulated here via $HOME, since the
+// system layer lives at a fixed, unwritable-in-tests path) had no effect on
+// a `when` clause in the project's own config, because the project layer's
+// subtree was already pruned by the time LoadLayers merged the home layer's
+// flags in.
+func TestLoadLayersMergesFlagsAcrossLayersBeforeEvaluatingWhen(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".fabrikate"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".fabrikate", "test.yaml"), []byte("flags:\n  ha: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	const configYAML = `
+config:
+  cluster:
+    when: flags.ha
+    replicas: 5
+`
+	if err := os.WriteFile(filepath.Join(dir, "config", "test.yaml"), []byte(configYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := NewComponentConfig(dir)
+	if err := cc.LoadLayers("test"); err != nil {
+		t.Fatalf("LoadLayers: %v", err)
+	}
+
+	cluster, ok := cc.Config["cluster"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected 'cluster' to survive now that the home layer's flags.ha=true is merged in before 'when' is evaluated, got %#v", cc.Config["cluster"])
+	}
+	if fmt.Sprintf("%v", cluster["replicas"]) != "5" {
+		t.Fatalf("expected cluster.replicas to be 5, got %v", cluster["replicas"])
+	}
+}
@@ -0,0 +1,101 @@
+// Package migrations upgrades ComponentConfig's on-disk YAML/JSON between
+// versioned schemas, keyed by the file's `apiVersion` field ("" denotes the
+// legacy, pre-apiVersion schema). Used by `fab config migrate`.
+package migrations
+
+import "fmt"
+
+// CurrentVersion is the schema version `fab config migrate` upgrades to by
+// default.
+const CurrentVersion = "fabrikate.io/v1"
+
+// versionOrder lists every schema version in ascending order; migrations
+// must be registered between consecutive entries.
+var versionOrder = []string{"", CurrentVersion}
+
+// MigrationFunc transforms a generically-decoded config document from one
+// schema version to the next.
+type MigrationFunc func(map[string]interface{}) (map[string]interface{}, error)
+
+// ValidateFunc reports whether a config document is well-formed for a given
+// schema version.
+type ValidateFunc func(map[string]interface{}) error
+
+type versionPair struct {
+	from string
+	to   string
+}
+
+var registry = map[versionPair]MigrationFunc{}
+var validators = map[string]ValidateFunc{}
+
+// Register adds a migration function between two consecutive schema
+// versions. It panics on a duplicate registration, since that indicates a
+// programming error rather than a runtime condition.
+func Register(from, to string, fn MigrationFunc) {
+	pair := versionPair{from, to}
+	if _, exists := registry[pair]; exists {
+		panic(fmt.Sprintf("migrations: duplicate registration for %q -> %q", from, to))
+	}
+	registry[pair] = fn
+}
+
+// RegisterValidator adds a structural validator for a schema version.
+func RegisterValidator(version string, fn ValidateFunc) {
+	validators[version] = fn
+}
+
+// Chain returns the ordered migration functions needed to go from -> to,
+// walking versionOrder one step at a time.
+func Chain(from, to string) ([]MigrationFunc, error) {
+	fromIndex, err := versionIndex(from)
+	if err != nil {
+		return nil, err
+	}
+	toIndex, err := versionIndex(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromIndex > toIndex {
+		return nil, fmt.Errorf("cannot migrate backwards from %q to %q", displayVersion(from), displayVersion(to))
+	}
+
+	var chain []MigrationFunc
+	for i := fromIndex; i < toIndex; i++ {
+		pair := versionPair{versionOrder[i], versionOrder[i+1]}
+		fn, ok := registry[pair]
+		if !ok {
+			return nil, fmt.Errorf("no migration registered from %q to %q", displayVersion(versionOrder[i]), displayVersion(versionOrder[i+1]))
+		}
+		chain = append(chain, fn)
+	}
+
+	return chain, nil
+}
+
+// Validate runs the registered validator for version against doc, if any.
+// A version with no registered validator always passes.
+func Validate(version string, doc map[string]interface{}) error {
+	validator, ok := validators[version]
+	if !ok {
+		return nil
+	}
+	return validator(doc)
+}
+
+func versionIndex(version string) (int, error) {
+	for i, v := range versionOrder {
+		if v == version {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown schema version %q", displayVersion(version))
+}
+
+func displayVersion(version string) string {
+	if version == "" {
+		return "<legacy>"
+	}
+	return version
+}
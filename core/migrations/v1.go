@@ -0,0 +1,64 @@
+package migrations
+
+import "fmt"
+
+func init() {
+	Register("", CurrentVersion, hoistNamespace)
+	RegisterValidator(CurrentVersion, validateV1)
+}
+
+// hoistNamespace moves a legacy `config.namespace` entry to the top-level
+// `namespace` field introduced in fabrikate.io/v1, since namespace is
+// structural metadata about the component rather than a config value.
+func hoistNamespace(doc map[string]interface{}) (map[string]interface{}, error) {
+	config, ok := doc["config"].(map[string]interface{})
+	if !ok {
+		return doc, nil
+	}
+
+	namespace, ok := config["namespace"]
+	if !ok {
+		return doc, nil
+	}
+
+	namespaceStr, ok := namespace.(string)
+	if !ok {
+		return nil, fmt.Errorf("config.namespace must be a string, got %T", namespace)
+	}
+
+	if _, alreadySet := doc["namespace"]; !alreadySet {
+		doc["namespace"] = namespaceStr
+	}
+
+	delete(config, "namespace")
+	if len(config) == 0 {
+		delete(doc, "config")
+	}
+
+	return doc, nil
+}
+
+// validateV1 checks the structural shape fabrikate.io/v1 expects. This is a
+// lightweight stand-in for full JSON Schema validation, which would require
+// a schema library this repo doesn't otherwise depend on.
+func validateV1(doc map[string]interface{}) error {
+	if namespace, ok := doc["namespace"]; ok {
+		if _, isString := namespace.(string); !isString {
+			return fmt.Errorf("namespace must be a string, got %T", namespace)
+		}
+	}
+
+	if config, ok := doc["config"]; ok {
+		if _, isMap := config.(map[string]interface{}); !isMap {
+			return fmt.Errorf("config must be a map, got %T", config)
+		}
+	}
+
+	if subcomponents, ok := doc["subcomponents"]; ok {
+		if _, isMap := subcomponents.(map[string]interface{}); !isMap {
+			return fmt.Errorf("subcomponents must be a map, got %T", subcomponents)
+		}
+	}
+
+	return nil
+}
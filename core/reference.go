@@ -0,0 +1,320 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Reference is a typed pointer to a value stored outside of config/*.yaml
+// (an environment variable, a file on disk, a Key Vault secret, or a
+// Kubernetes Secret) rather than the literal value itself. Exactly one of
+// its fields should be set; ComponentConfig.Resolve substitutes the
+// referenced value in-memory without ever persisting it back to disk.
+type Reference struct {
+	FromEnv       string              `yaml:"fromEnv,omitempty" json:"fromEnv,omitempty"`
+	FromFile      string              `yaml:"fromFile,omitempty" json:"fromFile,omitempty"`
+	FromKeyVault  *KeyVaultReference  `yaml:"fromKeyVault,omitempty" json:"fromKeyVault,omitempty"`
+	FromK8sSecret *K8sSecretReference `yaml:"fromK8sSecret,omitempty" json:"fromK8sSecret,omitempty"`
+}
+
+// KeyVaultReference identifies a single secret version in Azure Key Vault.
+type KeyVaultReference struct {
+	Vault string `yaml:"vault" json:"vault"`
+	Name  string `yaml:"name" json:"name"`
+}
+
+// K8sSecretReference identifies a single key within a Kubernetes Secret.
+type K8sSecretReference struct {
+	Name      string `yaml:"name" json:"name"`
+	Key       string `yaml:"key" json:"key"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+}
+
+// ParseReference parses the short form accepted by `fab set --ref`, e.g.
+// "env:DB_PASSWORD", "file:/run/secrets/apikey", "keyvault:prod-kv/token", or
+// "k8s:web/tls/tls.crt" (namespace/name/key).
+func ParseReference(ref string) (*Reference, error) {
+	source, value, found := strings.Cut(ref, ":")
+	if !found {
+		return nil, fmt.Errorf("'%s' is not a valid --ref; expected '<source>:<value>'", ref)
+	}
+
+	switch source {
+	case "env":
+		return &Reference{FromEnv: value}, nil
+	case "file":
+		return &Reference{FromFile: value}, nil
+	case "keyvault":
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("'%s' is not a valid keyvault ref; expected 'keyvault:<vault>/<name>'", ref)
+		}
+		return &Reference{FromKeyVault: &KeyVaultReference{Vault: parts[0], Name: parts[1]}}, nil
+	case "k8s":
+		parts := strings.SplitN(value, "/", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("'%s' is not a valid k8s ref; expected 'k8s:<namespace>/<name>/<key>'", ref)
+		}
+		return &Reference{FromK8sSecret: &K8sSecretReference{Namespace: parts[0], Name: parts[1], Key: parts[2]}}, nil
+	default:
+		return nil, fmt.Errorf("'%s' references an unknown source '%s'; expected one of env, file, keyvault, k8s", ref, source)
+	}
+}
+
+// asReference type-switches a decoded config leaf back into a Reference. A
+// Reference survives the generic map[string]interface{} unmarshal used for
+// Config as a nested map, so a node must be matched structurally rather than
+// by type assertion.
+func asReference(node interface{}) (*Reference, bool) {
+	asMap, ok := node.(map[string]interface{})
+	if !ok || len(asMap) != 1 {
+		return nil, false
+	}
+
+	ref := &Reference{}
+	switch {
+	case asMap["fromEnv"] != nil:
+		env, ok := asMap["fromEnv"].(string)
+		if !ok {
+			return nil, false
+		}
+		ref.FromEnv = env
+	case asMap["fromFile"] != nil:
+		file, ok := asMap["fromFile"].(string)
+		if !ok {
+			return nil, false
+		}
+		ref.FromFile = file
+	case asMap["fromKeyVault"] != nil:
+		kv, ok := asMap["fromKeyVault"].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		vault, _ := kv["vault"].(string)
+		name, _ := kv["name"].(string)
+		ref.FromKeyVault = &KeyVaultReference{Vault: vault, Name: name}
+	case asMap["fromK8sSecret"] != nil:
+		k8s, ok := asMap["fromK8sSecret"].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, _ := k8s["name"].(string)
+		key, _ := k8s["key"].(string)
+		namespace, _ := k8s["namespace"].(string)
+		ref.FromK8sSecret = &K8sSecretReference{Name: name, Key: key, Namespace: namespace}
+	default:
+		return nil, false
+	}
+
+	return ref, true
+}
+
+// asConfigNode turns a Reference back into the map shape the YAML/JSON
+// parser recognizes, so SetComponentConfigRef can store it alongside
+// literal values in Config without a dedicated field.
+func (r Reference) asConfigNode() map[string]interface{} {
+	switch {
+	case r.FromEnv != "":
+		return map[string]interface{}{"fromEnv": r.FromEnv}
+	case r.FromFile != "":
+		return map[string]interface{}{"fromFile": r.FromFile}
+	case r.FromKeyVault != nil:
+		return map[string]interface{}{"fromKeyVault": map[string]interface{}{"vault": r.FromKeyVault.Vault, "name": r.FromKeyVault.Name}}
+	case r.FromK8sSecret != nil:
+		return map[string]interface{}{"fromK8sSecret": map[string]interface{}{"name": r.FromK8sSecret.Name, "key": r.FromK8sSecret.Key, "namespace": r.FromK8sSecret.Namespace}}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// ReferenceResolver resolves a single Reference to its concrete value.
+// Implementations are expected to be cheap to construct and safe to reuse
+// across many Resolve calls within a single install/generate run.
+type ReferenceResolver interface {
+	Resolve(ctx context.Context, ref Reference) (string, error)
+}
+
+// DefaultReferenceResolver dispatches each Reference to the resolver
+// registered for whichever of its source fields is populated.
+type DefaultReferenceResolver struct {
+	Env       EnvReferenceResolver
+	File      FileReferenceResolver
+	KeyVault  KeyVaultReferenceResolver
+	K8sSecret K8sSecretReferenceResolver
+}
+
+// NewDefaultReferenceResolver returns a DefaultReferenceResolver wired up
+// with the built-in env, file, Key Vault, and Kubernetes Secret resolvers.
+func NewDefaultReferenceResolver() *DefaultReferenceResolver {
+	return &DefaultReferenceResolver{}
+}
+
+// Resolve implements ReferenceResolver.
+func (r *DefaultReferenceResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	switch {
+	case ref.FromEnv != "":
+		return r.Env.Resolve(ctx, ref)
+	case ref.FromFile != "":
+		return r.File.Resolve(ctx, ref)
+	case ref.FromKeyVault != nil:
+		return r.KeyVault.Resolve(ctx, ref)
+	case ref.FromK8sSecret != nil:
+		return r.K8sSecret.Resolve(ctx, ref)
+	default:
+		return "", fmt.Errorf("reference has no source set")
+	}
+}
+
+// EnvReferenceResolver resolves a Reference from a local environment variable.
+type EnvReferenceResolver struct{}
+
+// Resolve implements ReferenceResolver.
+func (EnvReferenceResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	value, ok := os.LookupEnv(ref.FromEnv)
+	if !ok {
+		return "", fmt.Errorf("environment variable '%s' referenced by fromEnv is not set", ref.FromEnv)
+	}
+	return value, nil
+}
+
+// FileReferenceResolver resolves a Reference from a file on the local disk,
+// e.g. a secret mounted by the orchestrator at a well-known path.
+type FileReferenceResolver struct{}
+
+// Resolve implements ReferenceResolver.
+func (FileReferenceResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	contents, err := ioutil.ReadFile(ref.FromFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read fromFile reference '%s': %w", ref.FromFile, err)
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+// KeyVaultReferenceResolver resolves a Reference from an Azure Key Vault
+// secret using ambient credentials (azidentity.NewDefaultAzureCredential:
+// environment variables, managed identity, or the Azure CLI, in that
+// order). Clients are cached per vault, since a single install/generate run
+// may resolve many secrets out of the same vault.
+type KeyVaultReferenceResolver struct {
+	mu      sync.Mutex
+	clients map[string]*azsecrets.Client
+}
+
+// Resolve implements ReferenceResolver.
+func (r *KeyVaultReferenceResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if ref.FromKeyVault == nil {
+		return "", fmt.Errorf("reference has no fromKeyVault set")
+	}
+
+	client, err := r.clientFor(ref.FromKeyVault.Vault)
+	if err != nil {
+		return "", fmt.Errorf("building Key Vault client for vault '%s': %w", ref.FromKeyVault.Vault, err)
+	}
+
+	// "" requests the current version of the secret.
+	resp, err := client.GetSecret(ctx, ref.FromKeyVault.Name, "", nil)
+	if err != nil {
+		return "", fmt.Errorf("fetching secret '%s' from vault '%s': %w", ref.FromKeyVault.Name, ref.FromKeyVault.Vault, err)
+	}
+	if resp.Value == nil {
+		return "", fmt.Errorf("secret '%s' in vault '%s' has no value", ref.FromKeyVault.Name, ref.FromKeyVault.Vault)
+	}
+
+	return *resp.Value, nil
+}
+
+func (r *KeyVaultReferenceResolver) clientFor(vault string) (*azsecrets.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clients == nil {
+		r.clients = map[string]*azsecrets.Client{}
+	}
+	if client, ok := r.clients[vault]; ok {
+		return client, nil
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := azsecrets.NewClient(fmt.Sprintf("https://%s.vault.azure.net/", vault), credential, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.clients[vault] = client
+	return client, nil
+}
+
+// K8sSecretReferenceResolver resolves a Reference from a key within a
+// Kubernetes Secret, using in-cluster config when available and otherwise
+// falling back to the current kubeconfig context.
+type K8sSecretReferenceResolver struct {
+	mu        sync.Mutex
+	clientset kubernetes.Interface
+}
+
+// Resolve implements ReferenceResolver.
+func (r *K8sSecretReferenceResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if ref.FromK8sSecret == nil {
+		return "", fmt.Errorf("reference has no fromK8sSecret set")
+	}
+
+	clientset, err := r.client()
+	if err != nil {
+		return "", fmt.Errorf("building Kubernetes client: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(ref.FromK8sSecret.Namespace).Get(ctx, ref.FromK8sSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("fetching secret '%s/%s': %w", ref.FromK8sSecret.Namespace, ref.FromK8sSecret.Name, err)
+	}
+
+	value, ok := secret.Data[ref.FromK8sSecret.Key]
+	if !ok {
+		return "", fmt.Errorf("secret '%s/%s' has no key '%s'", ref.FromK8sSecret.Namespace, ref.FromK8sSecret.Name, ref.FromK8sSecret.Key)
+	}
+
+	return string(value), nil
+}
+
+func (r *K8sSecretReferenceResolver) client() (kubernetes.Interface, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.clientset != nil {
+		return r.clientset, nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{},
+		).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("no in-cluster config and no usable kubeconfig: %w", err)
+		}
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	r.clientset = clientset
+	return clientset, nil
+}
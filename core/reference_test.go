@@ -0,0 +1,43 @@
+package core
+
+import "testing"
+
+// TestSetComponentConfigRefSurvivesMerge confirms that a Reference set via
+// SetComponentConfigRef keeps its identity (rather than being flattened
+// into a plain map, or resolved) after Merge layers an environment overlay
+// on top, since Merge is what Load uses to apply environment-specific
+// config files.
+func TestSetComponentConfigRefSurvivesMerge(t *testing.T) {
+	cc := NewComponentConfig(".")
+
+	ref := Reference{FromKeyVault: &KeyVaultReference{Vault: "prod-kv", Name: "db-password"}}
+	if err := cc.SetComponentConfigRef([]string{"db", "password"}, ref); err != nil {
+		t.Fatalf("SetComponentConfigRef: %v", err)
+	}
+
+	overlay := NewComponentConfig(".")
+	if err := overlay.SetComponentConfig([]string{"db", "replicas"}, "3"); err != nil {
+		t.Fatalf("SetComponentConfig on overlay: %v", err)
+	}
+
+	if err := cc.Merge(overlay); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	db, ok := cc.Config["db"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected db to be a map after merge, got %T", cc.Config["db"])
+	}
+
+	if db["replicas"] != "3" {
+		t.Fatalf("expected overlay value 'db.replicas' to survive the merge, got %v", db["replicas"])
+	}
+
+	resolved, ok := asReference(db["password"])
+	if !ok {
+		t.Fatalf("expected 'db.password' to still be a Reference after merge, got %#v", db["password"])
+	}
+	if resolved.FromKeyVault == nil || resolved.FromKeyVault.Vault != "prod-kv" || resolved.FromKeyVault.Name != "db-password" {
+		t.Fatalf("Reference identity did not survive the merge, got %#v", resolved)
+	}
+}
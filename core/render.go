@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	yaml "github.com/timfpark/yaml"
+)
+
+// TemplateScope is the data context that Go templates embedded in
+// ComponentConfig values are executed against, e.g.
+// "{{ .Environment }}-{{ .Config.region }}-eks" or
+// "{{ .Subcomponents.api.Config.repo }}:{{ .Version }}".
+type TemplateScope struct {
+	Config        map[string]interface{}
+	Subcomponents map[string]TemplateScope
+	Environment   string
+	Namespace     string
+	Version       string
+}
+
+// templateFuncs is the small sprig-like helper set available to config
+// templates.
+var templateFuncs = template.FuncMap{
+	"default": func(defaultValue, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return defaultValue
+		}
+		return value
+	},
+	"required": func(message string, value interface{}) (interface{}, error) {
+		if value == nil || value == "" {
+			return nil, fmt.Errorf(message)
+		}
+		return value, nil
+	},
+	"toYaml": func(value interface{}) (string, error) {
+		marshaled, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(marshaled), "\n"), nil
+	},
+	"quote": func(value interface{}) string {
+		return fmt.Sprintf("%q", value)
+	},
+	"env": func(name string) string {
+		return os.Getenv(name)
+	},
+}
+
+// Render expands Go-template expressions in every string leaf of Config and
+// Subcomponents, in place, against scope. Subcomponents are rendered before
+// this component's own Config so that a parent value may reference an
+// already-resolved subcomponent value. Render must run after Merge (so
+// environment overlays are already applied) and before Write; it is
+// idempotent for leaves that contain no template actions.
+func (cc *ComponentConfig) Render(scope TemplateScope) (err error) {
+	subcomponentScopes := map[string]TemplateScope{}
+
+	for name, subcomponentConfig := range cc.Subcomponents {
+		subScope := scope
+		subScope.Namespace = subcomponentConfig.Namespace
+		if subScope.Namespace == "" {
+			subScope.Namespace = scope.Namespace
+		}
+
+		if err := subcomponentConfig.Render(subScope); err != nil {
+			return fmt.Errorf("subcomponent '%s': %w", name, err)
+		}
+		cc.Subcomponents[name] = subcomponentConfig
+		subcomponentScopes[name] = subcomponentConfig.asTemplateScope()
+	}
+
+	scope.Config = cc.Config
+	scope.Subcomponents = subcomponentScopes
+
+	return renderConfigMap(cc.Config, scope)
+}
+
+// asTemplateScope builds the (already-rendered) TemplateScope a parent
+// component uses to reference this component's values, e.g.
+// "{{ .Subcomponents.api.Config.repo }}".
+func (cc *ComponentConfig) asTemplateScope() TemplateScope {
+	subScopes := map[string]TemplateScope{}
+	for name, subcomponentConfig := range cc.Subcomponents {
+		subScopes[name] = subcomponentConfig.asTemplateScope()
+	}
+
+	return TemplateScope{
+		Config:        cc.Config,
+		Subcomponents: subScopes,
+		Namespace:     cc.Namespace,
+	}
+}
+
+// configLeaf is a single string-valued entry in a (possibly nested) config
+// map, addressed by its dotted path (e.g. "db.host").
+type configLeaf struct {
+	path string
+	get  func() string
+	set  func(string)
+}
+
+// renderConfigMap renders every string leaf of config, resolving
+// cross-references between sibling keys (e.g. `clusterName` referencing
+// `region`) in dependency order, and fails with a descriptive error if two
+// leaves reference each other in a cycle.
+func renderConfigMap(config map[string]interface{}, scope TemplateScope) error {
+	leaves := flattenConfigLeaves(config, "")
+
+	templates := map[string]*template.Template{}
+	dependsOn := map[string][]string{}
+	for _, leaf := range leaves {
+		text := leaf.get()
+		tmpl, err := template.New(leaf.path).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return fmt.Errorf("parsing template for '%s': %w", leaf.path, err)
+		}
+		templates[leaf.path] = tmpl
+		dependsOn[leaf.path] = configRefsIn(tmpl)
+	}
+
+	order, err := topoSortConfigLeaves(dependsOn)
+	if err != nil {
+		return err
+	}
+
+	leavesByPath := map[string]configLeaf{}
+	for _, leaf := range leaves {
+		leavesByPath[leaf.path] = leaf
+	}
+
+	for _, path := range order {
+		leaf, ok := leavesByPath[path]
+		if !ok {
+			// Referenced a key that isn't a string leaf (e.g. a map or a
+			// value with no template actions); nothing to render for it.
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := templates[path].Execute(&buf, scope); err != nil {
+			return fmt.Errorf("rendering template for '%s': %w", path, err)
+		}
+		leaf.set(buf.String())
+	}
+
+	return nil
+}
+
+// flattenConfigLeaves walks a config map and returns an accessor/mutator
+// pair for every string-valued leaf, addressed by its dotted path relative
+// to the root of config.
+func flattenConfigLeaves(config map[string]interface{}, prefix string) (leaves []configLeaf) {
+	for key, value := range config {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch typed := value.(type) {
+		case string:
+			key, m := key, config
+			leaves = append(leaves, configLeaf{
+				path: path,
+				get:  func() string { return m[key].(string) },
+				set:  func(rendered string) { m[key] = rendered },
+			})
+		case map[string]interface{}:
+			leaves = append(leaves, flattenConfigLeaves(typed, path)...)
+		}
+	}
+
+	return leaves
+}
+
+// configRefsIn returns the dotted Config paths (e.g. "db.host") that tmpl's
+// parse tree references via ".Config.<path>".
+func configRefsIn(tmpl *template.Template) (refs []string) {
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.FieldNode:
+			if len(n.Ident) >= 2 && n.Ident[0] == "Config" {
+				refs = append(refs, strings.Join(n.Ident[1:], "."))
+			}
+		case *parse.ListNode:
+			if n != nil {
+				for _, child := range n.Nodes {
+					walk(child)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			for _, cmd := range n.Cmds {
+				for _, arg := range cmd.Args {
+					walk(arg)
+				}
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+
+	walk(tmpl.Tree.Root)
+	return refs
+}
+
+// topoSortConfigLeaves orders config leaf paths so that every leaf is
+// rendered after the leaves it depends on, returning a descriptive error
+// identifying the cycle path (e.g. "region -> clusterName -> region") if one
+// exists.
+func topoSortConfigLeaves(dependsOn map[string][]string) (order []string, err error) {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := map[string]int{}
+	var path []string
+
+	var visit func(node string) error
+	visit = func(node string) error {
+		switch state[node] {
+		case done:
+			return nil
+		case inProgress:
+			cycleStart := 0
+			for i, p := range path {
+				if p == node {
+					cycleStart = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[cycleStart:]...), node)
+			return fmt.Errorf("cycle detected in config template references: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[node] = inProgress
+		path = append(path, node)
+
+		deps := append([]string{}, dependsOn[node]...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[node] = done
+		order = append(order, node)
+		return nil
+	}
+
+	nodes := make([]string, 0, len(dependsOn))
+	for node := range dependsOn {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		if err := visit(node); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
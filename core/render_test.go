@@ -0,0 +1,47 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRenderConfigMapCycleError confirms a cycle between sibling config
+// leaves (region referencing clusterName and vice versa) fails with an
+// error identifying the cycle path, rather than panicking or silently
+// rendering one side first.
+func TestRenderConfigMapCycleError(t *testing.T) {
+	config := map[string]interface{}{
+		"region":      "{{ .Config.clusterName }}-region",
+		"clusterName": "{{ .Config.region }}-cluster",
+	}
+
+	err := renderConfigMap(config, TemplateScope{Config: config})
+	if err == nil {
+		t.Fatal("expected a cycle error, got nil")
+	}
+
+	if !strings.Contains(err.Error(), "cycle detected") {
+		t.Fatalf("expected error to report a cycle, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "->") {
+		t.Fatalf("expected error to include the cycle path, got: %v", err)
+	}
+}
+
+// TestRenderConfigMapResolvesDependencyOrder confirms that a leaf
+// referencing a sibling is rendered after that sibling, regardless of map
+// iteration order.
+func TestRenderConfigMapResolvesDependencyOrder(t *testing.T) {
+	config := map[string]interface{}{
+		"region":      "us-east-1",
+		"clusterName": "{{ .Config.region }}-cluster",
+	}
+
+	if err := renderConfigMap(config, TemplateScope{Config: config}); err != nil {
+		t.Fatalf("renderConfigMap: %v", err)
+	}
+
+	if config["clusterName"] != "us-east-1-cluster" {
+		t.Fatalf("expected clusterName to resolve region's value, got %v", config["clusterName"])
+	}
+}